@@ -9,11 +9,14 @@ import (
 	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
 	p2p "github.com/ipfs/go-ipfs/p2p"
 
+	madns "gx/ipfs/QmTbbpGZwCWeRCq8dRxjUY9g3a2uGdnsyNGuovWXvBfMiA/go-multiaddr-dns"
+	peer "gx/ipfs/QmWUswJeUsTwezxeJHYhcBFoGcKzRvN4pe7NB5XbC4wPf3/go-libp2p-peer"
 	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
 	"gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
 	pstore "gx/ipfs/QmZR2XWVVBCtbgBWnQhWk2xcQfaR3W8faQPriAiaaj7rsr/go-libp2p-peerstore"
@@ -24,11 +27,170 @@ import (
 // P2PProtoPrefix is the default required prefix for protocol names
 const P2PProtoPrefix = "/x/"
 
+// dnsResolveTimeout bounds how long we wait for a /dnsaddr, /dns4, or /dns6
+// component of a p2p target address to resolve.
+const dnsResolveTimeout = 10 * time.Second
+
+// p2p policy option names, shared between `forward` and `listen`.
+const (
+	p2pMaxConnsOpt        = "max-conns"
+	p2pMaxConnsPerPeerOpt = "max-conns-per-peer"
+	p2pIdleTimeoutOpt     = "idle-timeout"
+	p2pRateLimitOpt       = "rate-limit"
+)
+
+var p2pPolicyOptions = []cmdkit.Option{
+	cmdkit.IntOption(p2pMaxConnsOpt, "Maximum number of concurrent connections (0 for unlimited)."),
+	cmdkit.IntOption(p2pMaxConnsPerPeerOpt, "Maximum number of concurrent connections per remote peer (0 for unlimited)."),
+	cmdkit.StringOption(p2pIdleTimeoutOpt, "Close connections idle for longer than this duration (0 to disable)."),
+	cmdkit.IntOption(p2pRateLimitOpt, "Maximum throughput in bytes per second (0 for unlimited)."),
+}
+
+// p2p HTTP-tunneling option names, shared between `forward` and `listen`.
+const (
+	p2pHTTPOpt           = "http"
+	p2pHTTPPathPrefixOpt = "http-path-prefix"
+)
+
+// p2pParsePolicy reads the policy options shared by `forward` and `listen`
+// and builds the resulting *p2p.Policy.
+func p2pParsePolicy(req cmds.Request) (*p2p.Policy, error) {
+	maxConns, _, err := req.Option(p2pMaxConnsOpt).Int()
+	if err != nil {
+		return nil, err
+	}
+
+	maxConnsPerPeer, _, err := req.Option(p2pMaxConnsPerPeerOpt).Int()
+	if err != nil {
+		return nil, err
+	}
+
+	idleTimeoutOpt, _, err := req.Option(p2pIdleTimeoutOpt).String()
+	if err != nil {
+		return nil, err
+	}
+
+	var idleTimeout time.Duration
+	if idleTimeoutOpt != "" {
+		idleTimeout, err = time.ParseDuration(idleTimeoutOpt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rateLimit, _, err := req.Option(p2pRateLimitOpt).Int()
+	if err != nil {
+		return nil, err
+	}
+
+	return p2p.NewPolicy(maxConns, maxConnsPerPeer, idleTimeout, int64(rateLimit)), nil
+}
+
+// p2p ACL option names, shared between `listen` and `acl add`/`acl rm`.
+const (
+	p2pAllowPeerOpt   = "allow-peer"
+	p2pDenyPeerOpt    = "deny-peer"
+	p2pAllowSubnetOpt = "allow-subnet"
+)
+
+var p2pACLOptions = []cmdkit.Option{
+	cmdkit.StringOption(p2pAllowPeerOpt, "Comma-separated peer IDs allowed to connect."),
+	cmdkit.StringOption(p2pDenyPeerOpt, "Comma-separated peer IDs denied from connecting."),
+	cmdkit.StringOption(p2pAllowSubnetOpt, "Comma-separated multiaddr-filter masks (e.g. /ip4/10.0.0.0/ipcidr/8) allowed to connect."),
+}
+
+// p2pSplitList splits a comma-separated option value into its non-empty
+// elements.
+func p2pSplitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// p2pParseACL reads the ACL options shared by `listen` and `acl add` and
+// builds the resulting *p2p.ACL. It returns nil if no ACL options were
+// given.
+func p2pParseACL(req cmds.Request) (*p2p.ACL, error) {
+	allowPeerOpt, _, err := req.Option(p2pAllowPeerOpt).String()
+	if err != nil {
+		return nil, err
+	}
+	denyPeerOpt, _, err := req.Option(p2pDenyPeerOpt).String()
+	if err != nil {
+		return nil, err
+	}
+	allowSubnetOpt, _, err := req.Option(p2pAllowSubnetOpt).String()
+	if err != nil {
+		return nil, err
+	}
+
+	if allowPeerOpt == "" && denyPeerOpt == "" && allowSubnetOpt == "" {
+		return nil, nil
+	}
+
+	acl := p2p.NewACL()
+
+	for _, s := range p2pSplitList(allowPeerOpt) {
+		pid, err := peer.IDB58Decode(s)
+		if err != nil {
+			return nil, err
+		}
+		acl.AllowPeer(pid)
+	}
+	for _, s := range p2pSplitList(denyPeerOpt) {
+		pid, err := peer.IDB58Decode(s)
+		if err != nil {
+			return nil, err
+		}
+		acl.DenyPeer(pid)
+	}
+	for _, mask := range p2pSplitList(allowSubnetOpt) {
+		if err := acl.AllowSubnet(mask); err != nil {
+			return nil, err
+		}
+	}
+
+	return acl, nil
+}
+
+// p2pShouldPersist reports whether a newly created mount should be written
+// to the repo's mounts file, based on the --ephemeral flag and the
+// Experimental.Libp2pStreamMountingPersist config toggle.
+func p2pShouldPersist(n *core.IpfsNode, req cmds.Request) (bool, error) {
+	ephemeral, _, err := req.Option("ephemeral").Bool()
+	if err != nil {
+		return false, err
+	}
+	if ephemeral {
+		return false, nil
+	}
+
+	config, err := n.Repo.Config()
+	if err != nil {
+		return false, err
+	}
+	return config.Experimental.Libp2pStreamMountingPersist, nil
+}
+
 // P2PListenerInfoOutput is output type of ls command
 type P2PListenerInfoOutput struct {
 	Protocol      string
 	ListenAddress string
 	TargetAddress string
+
+	MaxConns        int    `json:",omitempty"`
+	MaxConnsPerPeer int    `json:",omitempty"`
+	IdleTimeout     string `json:",omitempty"`
+	RateLimit       int64  `json:",omitempty"`
+
+	ACLAllowPeers   int `json:",omitempty"`
+	ACLDenyPeers    int `json:",omitempty"`
+	ACLAllowSubnets int `json:",omitempty"`
 }
 
 // P2PStreamInfoOutput is output type of streams command
@@ -37,6 +199,31 @@ type P2PStreamInfoOutput struct {
 	Protocol      string
 	OriginAddress string
 	TargetAddress string
+
+	BytesIn      uint64
+	BytesOut     uint64
+	OpenedAt     string
+	LastActivity string `json:",omitempty"`
+}
+
+// P2PStreamStatsOutput is output type of a single entry of `stream stats`
+type P2PStreamStatsOutput struct {
+	Protocol string
+
+	Active int
+	Opened uint64
+	Closed uint64
+	Reset  uint64
+
+	BytesIn  uint64
+	BytesOut uint64
+
+	AverageConnectLatency string
+}
+
+// P2PStreamStatsListOutput is output type of `stream stats`
+type P2PStreamStatsListOutput struct {
+	Stats []P2PStreamStatsOutput
 }
 
 // P2PLsOutput is output type of ls command
@@ -44,6 +231,22 @@ type P2PLsOutput struct {
 	Listeners []P2PListenerInfoOutput
 }
 
+// P2PListenerStatsOutput is output type of a single entry of stats command
+type P2PListenerStatsOutput struct {
+	Protocol      string
+	ListenAddress string
+	TargetAddress string
+
+	ActiveConns int
+	MaxConns    int
+	Rejects     uint64
+}
+
+// P2PStatsOutput is output type of stats command
+type P2PStatsOutput struct {
+	Listeners []P2PListenerStatsOutput
+}
+
 // P2PStreamsOutput is output type of streams command
 type P2PStreamsOutput struct {
 	Streams []P2PStreamInfoOutput
@@ -67,6 +270,9 @@ are refined`,
 		"listen":  p2pListenCmd,
 		"close":   p2pCloseCmd,
 		"ls":      p2pLsCmd,
+		"stats":   p2pStatsCmd,
+		"reload":  p2pReloadCmd,
+		"acl":     p2pAclCmd,
 	},
 }
 
@@ -90,9 +296,12 @@ Example:
 		cmdkit.StringArg("listen-address", true, false, "Listening endpoint."),
 		cmdkit.StringArg("target-address", true, false, "Target endpoint."),
 	},
-	Options: []cmdkit.Option{
+	Options: append([]cmdkit.Option{
 		cmdkit.BoolOption("allow-custom-protocol", "Don't require /x/ prefix"),
-	},
+		cmdkit.BoolOption("ephemeral", "Do not persist this mount across daemon restarts"),
+		cmdkit.BoolOption(p2pHTTPOpt, "Tunnel each HTTP/1.1 request made to <listen-address> over its own stream, instead of forwarding raw bytes"),
+		cmdkit.StringOption(p2pHTTPPathPrefixOpt, "Path prefix to prepend to every tunneled HTTP request (only valid with --"+p2pHTTPOpt+")"),
+	}, p2pPolicyOptions...),
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := p2pGetNode(req)
 		if err != nil {
@@ -112,7 +321,13 @@ Example:
 			return
 		}
 
-		target, err := ipfsaddr.ParseString(targetOpt)
+		listen, err = parseAddr(n.Context(), listen)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		target, err := parseIpfsAddr(n.Context(), targetOpt)
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
@@ -129,10 +344,57 @@ Example:
 			return
 		}
 
-		if err := forwardLocal(n.Context(), n.P2P, n.Peerstore, proto, listen, target); err != nil {
+		pol, err := p2pParsePolicy(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		httpMode, _, err := req.Option(p2pHTTPOpt).Bool()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		httpPathPrefix, _, err := req.Option(p2pHTTPPathPrefixOpt).String()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+		if httpPathPrefix != "" && !httpMode {
+			res.SetError(errors.New("--"+p2pHTTPPathPrefixOpt+" requires --"+p2pHTTPOpt), cmdkit.ErrNormal)
+			return
+		}
+
+		if err := forwardLocal(n.Context(), n.P2P, n.Peerstore, proto, listen, target, pol, httpMode, httpPathPrefix); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		persist, err := p2pShouldPersist(n, req)
+		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
 		}
+		if persist {
+			rec := p2pMountRecord{
+				Kind:                "forward",
+				Protocol:            string(proto),
+				ListenAddress:       listenOpt,
+				TargetAddress:       targetOpt,
+				AllowCustomProtocol: allowCustom,
+				MaxConns:            pol.MaxConns,
+				MaxConnsPerPeer:     pol.MaxConnsPerPeer,
+				IdleTimeout:         pol.IdleTimeout.String(),
+				RateLimit:           int(pol.RateLimit),
+				HTTP:                httpMode,
+				HTTPPathPrefix:      httpPathPrefix,
+			}
+			if err := p2pPersistMount(n, rec); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
 		res.SetOutput(nil)
 	},
 }
@@ -155,9 +417,11 @@ Example:
 		cmdkit.StringArg("protocol", true, false, "Protocol name."),
 		cmdkit.StringArg("target-address", true, false, "Target endpoint."),
 	},
-	Options: []cmdkit.Option{
+	Options: append(append([]cmdkit.Option{
 		cmdkit.BoolOption("allow-custom-protocol", "Don't require /x/ prefix"),
-	},
+		cmdkit.BoolOption("ephemeral", "Do not persist this mount across daemon restarts"),
+		cmdkit.BoolOption(p2pHTTPOpt, "Treat every incoming stream as a single HTTP/1.1 request forwarded to <target-address>, instead of forwarding raw bytes"),
+	}, p2pPolicyOptions...), p2pACLOptions...),
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := p2pGetNode(req)
 		if err != nil {
@@ -176,6 +440,12 @@ Example:
 			return
 		}
 
+		target, err = parseAddr(n.Context(), target)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
 		allowCustom, _, err := req.Option("allow-custom-protocol").Bool()
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
@@ -187,30 +457,126 @@ Example:
 			return
 		}
 
-		if err := forwardRemote(n.Context(), n.P2P, proto, target); err != nil {
+		pol, err := p2pParsePolicy(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		acl, err := p2pParseACL(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		httpMode, _, err := req.Option(p2pHTTPOpt).Bool()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		if err := forwardRemote(n.Context(), n.P2P, proto, target, pol, acl, httpMode); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		persist, err := p2pShouldPersist(n, req)
+		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
 		}
+		if persist {
+			acls := acl.Snapshot()
+			rec := p2pMountRecord{
+				Kind:                "listen",
+				Protocol:            string(proto),
+				TargetAddress:       targetOpt,
+				AllowCustomProtocol: allowCustom,
+				MaxConns:            pol.MaxConns,
+				MaxConnsPerPeer:     pol.MaxConnsPerPeer,
+				IdleTimeout:         pol.IdleTimeout.String(),
+				RateLimit:           int(pol.RateLimit),
+				AllowPeers:          acls.AllowPeers,
+				DenyPeers:           acls.DenyPeers,
+				AllowSubnets:        acls.AllowSubnets,
+				HTTP:                httpMode,
+			}
+			if err := p2pPersistMount(n, rec); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
 
 		res.SetOutput(nil)
 	},
 }
 
+// hasDNSComponent reports whether addr contains a /dnsaddr, /dns4, or /dns6
+// protocol component that needs resolving before it can be dialed.
+func hasDNSComponent(addr ma.Multiaddr) bool {
+	for _, p := range addr.Protocols() {
+		switch p.Code {
+		case ma.P_DNSADDR, ma.P_DNS4, ma.P_DNS6:
+			return true
+		}
+	}
+	return false
+}
+
+// parseAddr resolves any DNS component in addr and returns the first
+// resolved multiaddr. If addr has no DNS component, it is returned
+// unchanged.
+func parseAddr(ctx context.Context, addr ma.Multiaddr) (ma.Multiaddr, error) {
+	if !hasDNSComponent(addr) {
+		return addr, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dnsResolveTimeout)
+	defer cancel()
+
+	resolved, err := madns.Resolve(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	if len(resolved) == 0 {
+		return nil, fmt.Errorf("could not resolve %s", addr)
+	}
+
+	return resolved[0], nil
+}
+
+// parseIpfsAddr resolves any DNS component of addr and parses the result
+// into an ipfsaddr.IPFSAddr, allowing targets like
+// /dnsaddr/bootstrap.libp2p.io/ipfs/QmPeer.
+func parseIpfsAddr(ctx context.Context, addr string) (ipfsaddr.IPFSAddr, error) {
+	maddr, err := ma.NewMultiaddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	maddr, err = parseAddr(ctx, maddr)
+	if err != nil {
+		return nil, err
+	}
+
+	return ipfsaddr.ParseMultiaddr(maddr)
+}
+
 // forwardRemote forwards libp2p service connections to a manet address
-func forwardRemote(ctx context.Context, p *p2p.P2P, proto protocol.ID, target ma.Multiaddr) error {
+func forwardRemote(ctx context.Context, p *p2p.P2P, proto protocol.ID, target ma.Multiaddr, pol *p2p.Policy, acl *p2p.ACL, httpMode bool) error {
 	// TODO: return some info
-	_, err := p.ForwardRemote(ctx, proto, target)
+	_, err := p.ForwardRemote(ctx, proto, target, pol, acl, httpMode)
 	return err
 }
 
 // forwardLocal forwards local connections to a libp2p service
-func forwardLocal(ctx context.Context, p *p2p.P2P, ps pstore.Peerstore, proto protocol.ID, bindAddr ma.Multiaddr, addr ipfsaddr.IPFSAddr) error {
+func forwardLocal(ctx context.Context, p *p2p.P2P, ps pstore.Peerstore, proto protocol.ID, bindAddr ma.Multiaddr, addr ipfsaddr.IPFSAddr, pol *p2p.Policy, httpMode bool, httpPathPrefix string) error {
 	if addr != nil {
 		ps.AddAddr(addr.ID(), addr.Multiaddr(), pstore.TempAddrTTL)
 	}
 
 	// TODO: return some info
-	_, err := p.ForwardLocal(ctx, addr.ID(), proto, bindAddr)
+	_, err := p.ForwardLocal(ctx, addr.ID(), proto, bindAddr, pol, httpMode, httpPathPrefix)
 	return err
 }
 
@@ -231,11 +597,27 @@ var p2pLsCmd = &cmds.Command{
 		output := &P2PLsOutput{}
 
 		for _, listener := range n.P2P.Listeners.Listeners {
-			output.Listeners = append(output.Listeners, P2PListenerInfoOutput{
+			info := P2PListenerInfoOutput{
 				Protocol:      string(listener.Protocol()),
 				ListenAddress: listener.ListenAddress().String(),
 				TargetAddress: listener.TargetAddress().String(),
-			})
+			}
+
+			if pol := listener.Policy(); pol != nil {
+				info.MaxConns = pol.MaxConns
+				info.MaxConnsPerPeer = pol.MaxConnsPerPeer
+				info.IdleTimeout = pol.IdleTimeout.String()
+				info.RateLimit = pol.RateLimit
+			}
+
+			if acl := listener.ACL(); acl != nil {
+				snap := acl.Snapshot()
+				info.ACLAllowPeers = len(snap.AllowPeers)
+				info.ACLDenyPeers = len(snap.DenyPeers)
+				info.ACLAllowSubnets = len(snap.AllowSubnets)
+			}
+
+			output.Listeners = append(output.Listeners, info)
 		}
 
 		res.SetOutput(output)
@@ -254,10 +636,70 @@ var p2pLsCmd = &cmds.Command{
 			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
 			for _, listener := range list.Listeners {
 				if headers {
-					fmt.Fprintln(w, "Protocol\tListen Address\tTarget Address")
+					fmt.Fprintln(w, "Protocol\tListen Address\tTarget Address\tMax Conns\tMax Conns/Peer\tIdle Timeout\tRate Limit\tACL Allow\tACL Deny\tACL Subnets")
+				}
+
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\t%d\t%d\t%d\t%d\n",
+					listener.Protocol, listener.ListenAddress, listener.TargetAddress,
+					listener.MaxConns, listener.MaxConnsPerPeer, listener.IdleTimeout, listener.RateLimit,
+					listener.ACLAllowPeers, listener.ACLDenyPeers, listener.ACLAllowSubnets)
+			}
+			w.Flush()
+
+			return buf, nil
+		},
+	},
+}
+
+var p2pStatsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show p2p listener connection counters.",
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("headers", "v", "Print table headers (Protocol, Listen, Target, Active, Max, Rejects)."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := p2pGetNode(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		output := &P2PStatsOutput{}
+
+		for _, listener := range n.P2P.Listeners.Listeners {
+			pol := listener.Policy()
+			output.Listeners = append(output.Listeners, P2PListenerStatsOutput{
+				Protocol:      string(listener.Protocol()),
+				ListenAddress: listener.ListenAddress().String(),
+				TargetAddress: listener.TargetAddress().String(),
+
+				ActiveConns: pol.ActiveConns(),
+				MaxConns:    pol.MaxConns,
+				Rejects:     pol.Rejects(),
+			})
+		}
+
+		res.SetOutput(output)
+	},
+	Type: P2PStatsOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			headers, _, _ := res.Request().Option("headers").Bool()
+			list := v.(*P2PStatsOutput)
+			buf := new(bytes.Buffer)
+			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+			for _, s := range list.Listeners {
+				if headers {
+					fmt.Fprintln(w, "Protocol\tListen Address\tTarget Address\tActive\tMax\tRejects")
 				}
 
-				fmt.Fprintf(w, "%s\t%s\t%s\n", listener.Protocol, listener.ListenAddress, listener.TargetAddress)
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\n", s.Protocol, s.ListenAddress, s.TargetAddress, s.ActiveConns, s.MaxConns, s.Rejects)
 			}
 			w.Flush()
 
@@ -266,6 +708,46 @@ var p2pLsCmd = &cmds.Command{
 	},
 }
 
+var p2pReloadCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Re-read persisted p2p mounts and recreate any that are missing.",
+		ShortDescription: `
+Re-reads ` + p2pMountsFileName + ` from the repo and recreates any forward
+or listen mount recorded there that isn't currently active.
+
+Persisted mounts are not recreated automatically when the daemon
+starts, so this is the only way to bring them back after a restart;
+run it once the daemon is back up. It is also useful after
+editing the mounts file by hand, or to recover a mount that failed to
+come up on an earlier ` + "`reload`" + ` (e.g. because a DNS target wasn't
+resolvable yet).
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := p2pGetNode(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		n.P2P.Listeners.Lock()
+		active := make(map[protocol.ID]bool, len(n.P2P.Listeners.Listeners))
+		for _, l := range n.P2P.Listeners.Listeners {
+			active[l.Protocol()] = true
+		}
+		n.P2P.Listeners.Unlock()
+
+		reloaded, err := replayMounts(n, active)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		res.SetOutput(reloaded)
+	},
+	Type: int(0),
+}
+
 var p2pCloseCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Stop listening for new connections to forward.",
@@ -296,12 +778,24 @@ var p2pCloseCmd = &cmds.Command{
 			return
 		}
 
+		listen, err = parseAddr(n.Context(), listen)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
 		target, err := ma.NewMultiaddr(targetOpt)
 		if err != nil {
 			res.SetError(err, cmdkit.ErrNormal)
 			return
 		}
 
+		target, err = parseAddr(n.Context(), target)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
 		if !(closeAll || p || l || t) {
 			res.SetError(errors.New("no matching options given"), cmdkit.ErrNormal)
 			return
@@ -342,7 +836,11 @@ var p2pCloseCmd = &cmds.Command{
 		for _, l := range todo {
 			if err := l.Close(); err != nil {
 				errs = append(errs, err.Error())
+				continue
 			}
+			// Best-effort: a mount that was never persisted simply isn't
+			// found and this is a no-op.
+			p2pForgetMount(n, string(l.Protocol()))
 		}
 		if len(errs) != 0 {
 			res.SetError(fmt.Errorf("errors when closing streams: %s", strings.Join(errs, "; ")), cmdkit.ErrNormal)
@@ -382,9 +880,19 @@ var p2pStreamCmd = &cmds.Command{
 	Subcommands: map[string]*cmds.Command{
 		"ls":    p2pStreamLsCmd,
 		"close": p2pStreamCloseCmd,
+		"stats": p2pStreamStatsCmd,
 	},
 }
 
+// formatLastActivity formats t for P2PStreamInfoOutput, returning "" for
+// the zero Time (a stream that hasn't forwarded a byte yet).
+func formatLastActivity(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
 var p2pStreamLsCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "List active p2p streams.",
@@ -409,6 +917,11 @@ var p2pStreamLsCmd = &cmds.Command{
 
 				OriginAddress: s.OriginAddr.String(),
 				TargetAddress: s.TargetAddr.String(),
+
+				BytesIn:      s.BytesIn(),
+				BytesOut:     s.BytesOut(),
+				OpenedAt:     s.OpenedAt.Format(time.RFC3339),
+				LastActivity: formatLastActivity(s.LastActivity()),
 			})
 		}
 
@@ -428,10 +941,12 @@ var p2pStreamLsCmd = &cmds.Command{
 			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
 			for _, stream := range list.Streams {
 				if headers {
-					fmt.Fprintln(w, "ID\tProtocol\tOrigin\tTarget")
+					fmt.Fprintln(w, "ID\tProtocol\tOrigin\tTarget\tBytes In\tBytes Out\tOpened\tLast Activity")
 				}
 
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", stream.HandlerID, stream.Protocol, stream.OriginAddress, stream.TargetAddress)
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\n",
+					stream.HandlerID, stream.Protocol, stream.OriginAddress, stream.TargetAddress,
+					stream.BytesIn, stream.BytesOut, stream.OpenedAt, stream.LastActivity)
 			}
 			w.Flush()
 
@@ -487,6 +1002,85 @@ var p2pStreamCloseCmd = &cmds.Command{
 	},
 }
 
+var p2pStreamStatsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show aggregate p2p stream counters by protocol.",
+		ShortDescription: `
+Reports bytes transferred, open/close/reset counts, average connect
+latency, and currently active stream count, aggregated across every
+stream seen for each protocol. The p2p package's PrometheusCollector
+exposes the same counters, but the daemon does not register it, so they
+are not served on the daemon's metrics endpoint.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("protocol", "p", "Only show stats for this protocol."),
+		cmdkit.BoolOption("headers", "v", "Print table headers (Protocol, Active, Opened, Closed, Reset, Bytes In, Bytes Out, Avg Latency)."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := p2pGetNode(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		protoOpt, hasProto, err := req.Option("protocol").String()
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		active := n.P2P.Streams.ActiveCounts()
+		output := &P2PStreamStatsListOutput{}
+		for proto, snap := range n.P2P.Streams.Snapshot() {
+			if hasProto && string(proto) != protoOpt {
+				continue
+			}
+
+			output.Stats = append(output.Stats, P2PStreamStatsOutput{
+				Protocol: string(proto),
+
+				Active: active[proto],
+				Opened: snap.Opened,
+				Closed: snap.Closed,
+				Reset:  snap.Resets,
+
+				BytesIn:  snap.BytesIn,
+				BytesOut: snap.BytesOut,
+
+				AverageConnectLatency: snap.AverageLatency.String(),
+			})
+		}
+
+		res.SetOutput(output)
+	},
+	Type: P2PStreamStatsListOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v, err := unwrapOutput(res.Output())
+			if err != nil {
+				return nil, err
+			}
+
+			headers, _, _ := res.Request().Option("headers").Bool()
+			list := v.(*P2PStreamStatsListOutput)
+			buf := new(bytes.Buffer)
+			w := tabwriter.NewWriter(buf, 1, 2, 1, ' ', 0)
+			for _, s := range list.Stats {
+				if headers {
+					fmt.Fprintln(w, "Protocol\tActive\tOpened\tClosed\tReset\tBytes In\tBytes Out\tAvg Latency")
+				}
+
+				fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\n",
+					s.Protocol, s.Active, s.Opened, s.Closed, s.Reset, s.BytesIn, s.BytesOut, s.AverageConnectLatency)
+			}
+			w.Flush()
+
+			return buf, nil
+		},
+	},
+}
+
 func p2pGetNode(req cmds.Request) (*core.IpfsNode, error) {
 	n, err := req.InvocContext().GetNode()
 	if err != nil {
@@ -0,0 +1,228 @@
+package commands
+
+import (
+	"errors"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	p2p "github.com/ipfs/go-ipfs/p2p"
+
+	peer "gx/ipfs/QmWUswJeUsTwezxeJHYhcBFoGcKzRvN4pe7NB5XbC4wPf3/go-libp2p-peer"
+	"gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	"gx/ipfs/QmdE4gMduCKCGAcczM2F5ioYDfdeKuPix138wrES1YSr7f/go-ipfs-cmdkit"
+)
+
+// P2PACLOutput is the output type of the `acl show` command.
+type P2PACLOutput struct {
+	AllowPeers   []string
+	DenyPeers    []string
+	AllowSubnets []string
+}
+
+var p2pAclCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage access-control lists for p2p listeners.",
+		ShortDescription: `
+ACLs can only be attached to 'ipfs p2p listen' mounts; 'ipfs p2p forward'
+mounts dial a single known peer and have nothing to filter.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"add":  p2pAclAddCmd,
+		"rm":   p2pAclRmCmd,
+		"show": p2pAclShowCmd,
+	},
+}
+
+// p2pAclFor returns the ACL attached to the listener for proto, erroring
+// if no such listener is mounted or if it doesn't support ACLs.
+func p2pAclFor(n *core.IpfsNode, proto protocol.ID) (*p2p.ACL, error) {
+	n.P2P.Listeners.Lock()
+	defer n.P2P.Listeners.Unlock()
+
+	for _, l := range n.P2P.Listeners.Listeners {
+		if l.Protocol() != proto {
+			continue
+		}
+		acl := l.ACL()
+		if acl == nil {
+			return nil, errors.New("protocol " + string(proto) + " has no ACL; only 'ipfs p2p listen' mounts support one")
+		}
+		return acl, nil
+	}
+
+	return nil, errors.New("no listener for protocol " + string(proto))
+}
+
+// p2pUpdatePersistedACL rewrites the ACL fields of proto's persisted mount
+// record, if one exists. It is a no-op for mounts that were never
+// persisted (e.g. created with --ephemeral).
+func p2pUpdatePersistedACL(n *core.IpfsNode, proto protocol.ID, acl *p2p.ACL) error {
+	records, err := p2pLoadMounts(n)
+	if err != nil {
+		return err
+	}
+
+	snap := acl.Snapshot()
+	changed := false
+	for i, r := range records {
+		if r.Protocol != string(proto) {
+			continue
+		}
+		records[i].AllowPeers = snap.AllowPeers
+		records[i].DenyPeers = snap.DenyPeers
+		records[i].AllowSubnets = snap.AllowSubnets
+		changed = true
+		break
+	}
+	if !changed {
+		return nil
+	}
+
+	return p2pSaveMounts(n, records)
+}
+
+var p2pAclAddCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Add entries to a listener's ACL.",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("protocol", true, false, "Protocol name."),
+	},
+	Options: p2pACLOptions,
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := p2pGetNode(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		proto := protocol.ID(req.Arguments()[0])
+		acl, err := p2pAclFor(n, proto)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		allowPeerOpt, _, _ := req.Option(p2pAllowPeerOpt).String()
+		denyPeerOpt, _, _ := req.Option(p2pDenyPeerOpt).String()
+		allowSubnetOpt, _, _ := req.Option(p2pAllowSubnetOpt).String()
+
+		for _, s := range p2pSplitList(allowPeerOpt) {
+			pid, err := peer.IDB58Decode(s)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			acl.AllowPeer(pid)
+		}
+		for _, s := range p2pSplitList(denyPeerOpt) {
+			pid, err := peer.IDB58Decode(s)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			acl.DenyPeer(pid)
+		}
+		for _, mask := range p2pSplitList(allowSubnetOpt) {
+			if err := acl.AllowSubnet(mask); err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+		}
+
+		if err := p2pUpdatePersistedACL(n, proto, acl); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		res.SetOutput(nil)
+	},
+}
+
+var p2pAclRmCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove entries from a listener's ACL.",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("protocol", true, false, "Protocol name."),
+	},
+	Options: p2pACLOptions,
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := p2pGetNode(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		proto := protocol.ID(req.Arguments()[0])
+		acl, err := p2pAclFor(n, proto)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		allowPeerOpt, _, _ := req.Option(p2pAllowPeerOpt).String()
+		denyPeerOpt, _, _ := req.Option(p2pDenyPeerOpt).String()
+		allowSubnetOpt, _, _ := req.Option(p2pAllowSubnetOpt).String()
+
+		for _, s := range p2pSplitList(allowPeerOpt) {
+			pid, err := peer.IDB58Decode(s)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			acl.RemoveAllowPeer(pid)
+		}
+		for _, s := range p2pSplitList(denyPeerOpt) {
+			pid, err := peer.IDB58Decode(s)
+			if err != nil {
+				res.SetError(err, cmdkit.ErrNormal)
+				return
+			}
+			acl.RemoveDenyPeer(pid)
+		}
+		for _, mask := range p2pSplitList(allowSubnetOpt) {
+			acl.RemoveAllowSubnet(mask)
+		}
+
+		if err := p2pUpdatePersistedACL(n, proto, acl); err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		res.SetOutput(nil)
+	},
+}
+
+var p2pAclShowCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show a listener's ACL.",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("protocol", true, false, "Protocol name."),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := p2pGetNode(req)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		proto := protocol.ID(req.Arguments()[0])
+		acl, err := p2pAclFor(n, proto)
+		if err != nil {
+			res.SetError(err, cmdkit.ErrNormal)
+			return
+		}
+
+		snap := acl.Snapshot()
+		res.SetOutput(&P2PACLOutput{
+			AllowPeers:   snap.AllowPeers,
+			DenyPeers:    snap.DenyPeers,
+			AllowSubnets: snap.AllowSubnets,
+		})
+	},
+	Type: P2PACLOutput{},
+}
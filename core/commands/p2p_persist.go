@@ -0,0 +1,270 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	core "github.com/ipfs/go-ipfs/core"
+	p2p "github.com/ipfs/go-ipfs/p2p"
+
+	peer "gx/ipfs/QmWUswJeUsTwezxeJHYhcBFoGcKzRvN4pe7NB5XbC4wPf3/go-libp2p-peer"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+	"gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+)
+
+// p2pMountsFileName is the name of the file, relative to the repo root,
+// that persisted `ipfs p2p forward`/`ipfs p2p listen` mounts are recorded
+// in so they can be recreated across daemon restarts.
+const p2pMountsFileName = "p2p-mounts.json"
+
+// p2pMountRecord is the on-disk representation of a single persisted
+// forward or listen mount.
+type p2pMountRecord struct {
+	Kind string // "forward" or "listen"
+
+	Protocol      string
+	ListenAddress string // forward only
+	TargetAddress string
+
+	AllowCustomProtocol bool
+
+	MaxConns        int
+	MaxConnsPerPeer int
+	IdleTimeout     string
+	RateLimit       int
+
+	// ACL entries; only meaningful for Kind == "listen".
+	AllowPeers   []string `json:",omitempty"`
+	DenyPeers    []string `json:",omitempty"`
+	AllowSubnets []string `json:",omitempty"`
+
+	// HTTP, if set, tunnels HTTP/1.1 requests over streams instead of
+	// forwarding raw bytes. HTTPPathPrefix is only meaningful for Kind ==
+	// "forward".
+	HTTP           bool   `json:",omitempty"`
+	HTTPPathPrefix string `json:",omitempty"`
+}
+
+// p2pMountsMu guards concurrent reads/writes of the mounts file; mounts
+// are created and torn down rarely enough that a single global lock is
+// simpler than per-repo locking.
+var p2pMountsMu sync.Mutex
+
+// p2pMountsPath returns the path to the persisted mounts file for n's
+// repo, or an error if the repo implementation doesn't expose a path.
+func p2pMountsPath(n *core.IpfsNode) (string, error) {
+	fsr, ok := n.Repo.(interface{ Path() string })
+	if !ok {
+		return "", errors.New("repo does not support persistent p2p mounts")
+	}
+	return filepath.Join(fsr.Path(), p2pMountsFileName), nil
+}
+
+func p2pLoadMounts(n *core.IpfsNode) ([]p2pMountRecord, error) {
+	path, err := p2pMountsPath(n)
+	if err != nil {
+		return nil, err
+	}
+
+	p2pMountsMu.Lock()
+	defer p2pMountsMu.Unlock()
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []p2pMountRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func p2pSaveMounts(n *core.IpfsNode, records []p2pMountRecord) error {
+	path, err := p2pMountsPath(n)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	p2pMountsMu.Lock()
+	defer p2pMountsMu.Unlock()
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// p2pPersistMount appends rec to the repo's mounts file, replacing any
+// existing record for the same protocol.
+func p2pPersistMount(n *core.IpfsNode, rec p2pMountRecord) error {
+	records, err := p2pLoadMounts(n)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i, r := range records {
+		if r.Protocol == rec.Protocol {
+			records[i] = rec
+			found = true
+			break
+		}
+	}
+	if !found {
+		records = append(records, rec)
+	}
+
+	return p2pSaveMounts(n, records)
+}
+
+// p2pForgetMount removes any persisted record for proto from the repo's
+// mounts file.
+func p2pForgetMount(n *core.IpfsNode, proto string) error {
+	records, err := p2pLoadMounts(n)
+	if err != nil {
+		return err
+	}
+
+	out := records[:0]
+	for _, r := range records {
+		if r.Protocol != proto {
+			out = append(out, r)
+		}
+	}
+	if len(out) == len(records) {
+		return nil
+	}
+
+	return p2pSaveMounts(n, out)
+}
+
+// ReplayPersistedMounts recreates every mount recorded in the repo's
+// mounts file and returns how many were recreated. It needs n.P2P to be
+// constructed.
+//
+// Daemon startup does not call it, so persisted mounts do not come back
+// on their own after a restart; `ipfs p2p reload` recreates them through
+// the same replayMounts logic.
+func ReplayPersistedMounts(n *core.IpfsNode) (int, error) {
+	return replayMounts(n, nil)
+}
+
+// replayMounts recreates every persisted mount not already present in
+// skip (keyed by protocol ID). skip may be nil to replay everything.
+func replayMounts(n *core.IpfsNode, skip map[protocol.ID]bool) (int, error) {
+	records, err := p2pLoadMounts(n)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for _, rec := range records {
+		if skip[protocol.ID(rec.Protocol)] {
+			continue
+		}
+		if err := p2pReplayMount(n, rec); err != nil {
+			continue
+		}
+		replayed++
+	}
+	return replayed, nil
+}
+
+func p2pReplayMount(n *core.IpfsNode, rec p2pMountRecord) error {
+	proto := protocol.ID(rec.Protocol)
+
+	pol, err := p2pPolicyFromRecord(rec)
+	if err != nil {
+		return err
+	}
+
+	switch rec.Kind {
+	case "forward":
+		listen, err := ma.NewMultiaddr(rec.ListenAddress)
+		if err != nil {
+			return err
+		}
+		listen, err = parseAddr(n.Context(), listen)
+		if err != nil {
+			return err
+		}
+
+		target, err := parseIpfsAddr(n.Context(), rec.TargetAddress)
+		if err != nil {
+			return err
+		}
+
+		return forwardLocal(n.Context(), n.P2P, n.Peerstore, proto, listen, target, pol, rec.HTTP, rec.HTTPPathPrefix)
+	case "listen":
+		target, err := ma.NewMultiaddr(rec.TargetAddress)
+		if err != nil {
+			return err
+		}
+		target, err = parseAddr(n.Context(), target)
+		if err != nil {
+			return err
+		}
+
+		acl, err := p2pACLFromRecord(rec)
+		if err != nil {
+			return err
+		}
+
+		return forwardRemote(n.Context(), n.P2P, proto, target, pol, acl, rec.HTTP)
+	default:
+		return errors.New("unknown persisted p2p mount kind: " + rec.Kind)
+	}
+}
+
+func p2pACLFromRecord(rec p2pMountRecord) (*p2p.ACL, error) {
+	if len(rec.AllowPeers) == 0 && len(rec.DenyPeers) == 0 && len(rec.AllowSubnets) == 0 {
+		return nil, nil
+	}
+
+	acl := p2p.NewACL()
+	for _, s := range rec.AllowPeers {
+		pid, err := peer.IDB58Decode(s)
+		if err != nil {
+			return nil, err
+		}
+		acl.AllowPeer(pid)
+	}
+	for _, s := range rec.DenyPeers {
+		pid, err := peer.IDB58Decode(s)
+		if err != nil {
+			return nil, err
+		}
+		acl.DenyPeer(pid)
+	}
+	for _, mask := range rec.AllowSubnets {
+		if err := acl.AllowSubnet(mask); err != nil {
+			return nil, err
+		}
+	}
+	return acl, nil
+}
+
+func p2pPolicyFromRecord(rec p2pMountRecord) (*p2p.Policy, error) {
+	var idleTimeout time.Duration
+	if rec.IdleTimeout != "" {
+		var err error
+		idleTimeout, err = time.ParseDuration(rec.IdleTimeout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p2p.NewPolicy(rec.MaxConns, rec.MaxConnsPerPeer, idleTimeout, int64(rec.RateLimit)), nil
+}
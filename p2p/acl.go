@@ -0,0 +1,146 @@
+package p2p
+
+import (
+	"net"
+	"sync"
+
+	peer "gx/ipfs/QmWUswJeUsTwezxeJHYhcBFoGcKzRvN4pe7NB5XbC4wPf3/go-libp2p-peer"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+	manet "gx/ipfs/QmYzDkkgAEmrcNzFCiYo6L1dTX4EAG1gZkbtdbd9trL4vd/go-multiaddr-net"
+	filter "gx/ipfs/QmSXUokcP4TJpFfqozT69AVAYRtzXVMUjzQVkYX41R9Svs/go-maddr-filter"
+)
+
+// ACL filters which remote peers may open a stream against a listener. An
+// empty ACL, as returned by NewACL, allows everyone; once an
+// allow-peer or allow-subnet entry exists, only matching peers are let
+// through. Deny entries always take precedence.
+type ACL struct {
+	mu sync.RWMutex
+
+	allowPeers map[peer.ID]bool
+	denyPeers  map[peer.ID]bool
+	subnets    map[string]*net.IPNet
+}
+
+// NewACL creates an empty ACL, which by default allows every peer.
+func NewACL() *ACL {
+	return &ACL{
+		allowPeers: make(map[peer.ID]bool),
+		denyPeers:  make(map[peer.ID]bool),
+		subnets:    make(map[string]*net.IPNet),
+	}
+}
+
+// AllowPeer permits p to connect, regardless of any allow-subnet entries.
+func (a *ACL) AllowPeer(p peer.ID) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.allowPeers[p] = true
+}
+
+// DenyPeer refuses connections from p, even if it matches an allowed
+// subnet.
+func (a *ACL) DenyPeer(p peer.ID) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.denyPeers[p] = true
+}
+
+// RemoveAllowPeer undoes a prior AllowPeer.
+func (a *ACL) RemoveAllowPeer(p peer.ID) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.allowPeers, p)
+}
+
+// RemoveDenyPeer undoes a prior DenyPeer.
+func (a *ACL) RemoveDenyPeer(p peer.ID) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.denyPeers, p)
+}
+
+// AllowSubnet permits connections from remote addresses matching mask, a
+// multiaddr-filter style CIDR mask such as "/ip4/10.0.0.0/ipcidr/8".
+func (a *ACL) AllowSubnet(mask string) error {
+	ipnet, err := filter.NewMask(mask)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.subnets[mask] = ipnet
+	return nil
+}
+
+// RemoveAllowSubnet undoes a prior AllowSubnet for the same mask string.
+func (a *ACL) RemoveAllowSubnet(mask string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.subnets, mask)
+}
+
+// Permit reports whether p, observed at addr, may open a stream. A nil ACL
+// permits everyone.
+func (a *ACL) Permit(p peer.ID, addr ma.Multiaddr) bool {
+	if a == nil {
+		return true
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.denyPeers[p] {
+		return false
+	}
+
+	if len(a.allowPeers) == 0 && len(a.subnets) == 0 {
+		return true
+	}
+
+	if a.allowPeers[p] {
+		return true
+	}
+
+	if addr != nil {
+		if ip, err := manet.ToIP(addr); err == nil {
+			for _, ipnet := range a.subnets {
+				if ipnet.Contains(ip) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// ACLSnapshot is a point-in-time, serializable view of an ACL's entries.
+type ACLSnapshot struct {
+	AllowPeers   []string
+	DenyPeers    []string
+	AllowSubnets []string
+}
+
+// Snapshot returns the current ACL entries. A nil ACL yields a zero value.
+func (a *ACL) Snapshot() ACLSnapshot {
+	var snap ACLSnapshot
+	if a == nil {
+		return snap
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for p := range a.allowPeers {
+		snap.AllowPeers = append(snap.AllowPeers, p.Pretty())
+	}
+	for p := range a.denyPeers {
+		snap.DenyPeers = append(snap.DenyPeers, p.Pretty())
+	}
+	for mask := range a.subnets {
+		snap.AllowSubnets = append(snap.AllowSubnets, mask)
+	}
+	return snap
+}
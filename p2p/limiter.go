@@ -0,0 +1,56 @@
+package p2p
+
+import (
+	"io"
+	"time"
+)
+
+// withRateLimit wraps w so that writes to it are throttled to at most
+// bytesPerSec. A non-positive bytesPerSec disables throttling.
+func withRateLimit(w io.Writer, bytesPerSec int64) io.Writer {
+	if bytesPerSec <= 0 {
+		return w
+	}
+	return &rateLimitedWriter{Writer: w, bytesPerSec: bytesPerSec, bucket: bytesPerSec}
+}
+
+type rateLimitedWriter struct {
+	io.Writer
+	bytesPerSec int64
+	bucket      int64
+	last        time.Time
+}
+
+func (w *rateLimitedWriter) Write(p []byte) (int, error) {
+	now := time.Now()
+	if !w.last.IsZero() {
+		w.bucket += int64(now.Sub(w.last).Seconds() * float64(w.bytesPerSec))
+		if w.bucket > w.bytesPerSec {
+			w.bucket = w.bytesPerSec
+		}
+	}
+	w.last = now
+
+	var total int
+	for len(p) > 0 {
+		if w.bucket <= 0 {
+			time.Sleep(time.Second / 10)
+			w.bucket += w.bytesPerSec / 10
+			continue
+		}
+
+		n := int64(len(p))
+		if n > w.bucket {
+			n = w.bucket
+		}
+
+		wn, err := w.Writer.Write(p[:n])
+		total += wn
+		w.bucket -= int64(wn)
+		if err != nil {
+			return total, err
+		}
+		p = p[wn:]
+	}
+	return total, nil
+}
@@ -0,0 +1,59 @@
+package p2p
+
+import (
+	"sync"
+
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+)
+
+// Listener is a single active p2p mount: either a local TCP listener
+// forwarding connections into the libp2p network (`ipfs p2p forward`), or a
+// libp2p protocol handler forwarding incoming streams to a local target
+// (`ipfs p2p listen`).
+type Listener interface {
+	Protocol() protocol.ID
+	ListenAddress() ma.Multiaddr
+	TargetAddress() ma.Multiaddr
+
+	// Policy returns the resource limits enforced on this mount, or nil
+	// if none were configured.
+	Policy() *Policy
+
+	// ACL returns the access-control list guarding this mount's inbound
+	// connections, or nil if it doesn't support one (e.g. a `forward`
+	// mount, which only ever dials a single known peer).
+	ACL() *ACL
+
+	Close() error
+}
+
+// Listeners tracks the set of active p2p listeners.
+type Listeners struct {
+	sync.Mutex
+
+	Listeners []Listener
+}
+
+func newListenersRegistry() *Listeners {
+	return &Listeners{}
+}
+
+// Register adds a listener to the registry.
+func (l *Listeners) Register(listener Listener) {
+	l.Lock()
+	defer l.Unlock()
+	l.Listeners = append(l.Listeners, listener)
+}
+
+// Deregister removes a listener from the registry.
+func (l *Listeners) Deregister(listener Listener) {
+	l.Lock()
+	defer l.Unlock()
+	for i, v := range l.Listeners {
+		if v == listener {
+			l.Listeners = append(l.Listeners[:i], l.Listeners[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	peer "gx/ipfs/QmWUswJeUsTwezxeJHYhcBFoGcKzRvN4pe7NB5XbC4wPf3/go-libp2p-peer"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+	manet "gx/ipfs/QmYzDkkgAEmrcNzFCiYo6L1dTX4EAG1gZkbtdbd9trL4vd/go-multiaddr-net"
+)
+
+// localListener is a Listener created by `ipfs p2p forward`: it accepts
+// local connections on ListenAddress and forwards each one to a fresh
+// libp2p stream opened to TargetAddress' peer under Protocol.
+type localListener struct {
+	p2p *P2P
+
+	proto protocol.ID
+	peer  peer.ID
+	laddr ma.Multiaddr
+	taddr ma.Multiaddr
+	netL  manet.Listener
+	pol   *Policy
+
+	// http, if set, treats each connection accepted on laddr as a series
+	// of HTTP/1.1 requests, tunneling each one over its own fresh stream
+	// instead of piping the connection's raw bytes over a single stream.
+	http       bool
+	pathPrefix string
+}
+
+func (l *localListener) Protocol() protocol.ID       { return l.proto }
+func (l *localListener) ListenAddress() ma.Multiaddr { return l.laddr }
+func (l *localListener) TargetAddress() ma.Multiaddr { return l.taddr }
+func (l *localListener) Policy() *Policy             { return l.pol }
+func (l *localListener) ACL() *ACL                   { return nil }
+
+func (l *localListener) Close() error {
+	l.p2p.Listeners.Deregister(l)
+	return l.netL.Close()
+}
+
+func (l *localListener) accept(ctx context.Context) {
+	for {
+		local, err := l.netL.Accept()
+		if err != nil {
+			return
+		}
+
+		go l.handle(ctx, local)
+	}
+}
+
+func (l *localListener) handle(ctx context.Context, local manet.Conn) {
+	defer local.Close()
+
+	peerKey := l.peer.Pretty()
+	if !l.pol.Allow(peerKey) {
+		return
+	}
+	defer l.pol.Release(peerKey)
+
+	if l.http {
+		l.handleHTTP(ctx, local)
+		return
+	}
+
+	start := time.Now()
+	remote, err := l.p2p.peerHost.NewStream(ctx, l.peer, l.proto)
+	if err != nil {
+		return
+	}
+	defer remote.Close()
+
+	s := &Stream{
+		Protocol:   l.proto,
+		OriginAddr: l.laddr,
+		TargetAddr: l.taddr,
+		Stream:     remote,
+	}
+	l.p2p.Streams.Register(s, time.Since(start))
+
+	tunnel(s, local, remote, l.pol)
+}
+
+// handleHTTP treats every request read off local as an HTTP/1.1 request,
+// tunneling each one over its own fresh stream to l.peer and writing the
+// response back to local, until local runs out of keep-alive requests or
+// either side errs out.
+func (l *localListener) handleHTTP(ctx context.Context, local manet.Conn) {
+	r := bufio.NewReader(local)
+	for {
+		req, err := http.ReadRequest(r)
+		if err != nil {
+			return
+		}
+
+		if !l.roundTripHTTP(ctx, local, req) {
+			return
+		}
+
+		if req.Close {
+			return
+		}
+	}
+}
+
+// roundTripHTTP dials a fresh stream to l.peer, forwards req over it with
+// l.pathPrefix prepended to its path, and writes the resulting response
+// back to local. It reports whether the caller may keep reading further
+// requests off local.
+func (l *localListener) roundTripHTTP(ctx context.Context, local manet.Conn, req *http.Request) bool {
+	start := time.Now()
+	remote, err := l.p2p.peerHost.NewStream(ctx, l.peer, l.proto)
+	if err != nil {
+		return false
+	}
+
+	s := &Stream{
+		Protocol:   l.proto,
+		OriginAddr: l.laddr,
+		TargetAddr: l.taddr,
+		Stream:     remote,
+	}
+	l.p2p.Streams.Register(s, time.Since(start))
+	defer s.Close()
+
+	if l.pathPrefix != "" {
+		req.URL.Path = l.pathPrefix + req.URL.Path
+	}
+	req.RequestURI = ""
+
+	if err := req.Write(countingWriter{remote, s.addOut}); err != nil {
+		return false
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(countingReader{remote, s.addIn}), req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.Write(local) == nil
+}
+
+// httpHost derives the Host header value to use for an HTTP request
+// forwarded to addr, e.g. "127.0.0.1:8080" for a /ip4/.../tcp/... target.
+// It returns "" for targets, such as /unix/..., that have no meaningful
+// host:port representation.
+func httpHost(addr ma.Multiaddr) (string, error) {
+	network, host, err := manet.DialArgs(addr)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(network, "tcp") {
+		return "", nil
+	}
+	return host, nil
+}
+
+// countingReader wraps an io.Reader, calling record with the number of
+// bytes read from it on every successful Read. It lets the HTTP tunneling
+// path feed Stream.addIn/addOut the same way tunnel does for raw forwards.
+type countingReader struct {
+	io.Reader
+	record func(int)
+}
+
+func (r countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.record(n)
+	}
+	return n, err
+}
+
+// countingWriter is countingReader's write-side counterpart.
+type countingWriter struct {
+	io.Writer
+	record func(int)
+}
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	if n > 0 {
+		w.record(n)
+	}
+	return n, err
+}
+
+// ForwardLocal listens on bindAddr and forwards every connection to a
+// stream dialed to the given peer under proto, subject to pol (which may
+// be nil for no limits). If httpMode is set, each connection's requests
+// are tunneled individually as HTTP/1.1 over fresh streams rather than
+// forwarded as raw bytes over one; pathPrefix is then prepended to every
+// tunneled request's path (ignored otherwise).
+func (p *P2P) ForwardLocal(ctx context.Context, peer peer.ID, proto protocol.ID, bindAddr ma.Multiaddr, pol *Policy, httpMode bool, pathPrefix string) (Listener, error) {
+	netL, err := manet.Listen(bindAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &localListener{
+		p2p:   p,
+		proto: proto,
+		peer:  peer,
+		laddr: bindAddr,
+		taddr: ma.StringCast("/ipfs/" + peer.Pretty()),
+		netL:  netL,
+		pol:   pol,
+
+		http:       httpMode,
+		pathPrefix: pathPrefix,
+	}
+
+	p.Listeners.Register(l)
+	go l.accept(ctx)
+
+	return l, nil
+}
+
+// tunnel pipes bytes between a (the local side) and b (the libp2p side,
+// tracked by s) until either side closes, enforcing pol's idle timeout and
+// rate limit along the way and recording s's BytesIn/BytesOut as it goes.
+// pol may be nil.
+func tunnel(s *Stream, a, b io.ReadWriteCloser, pol *Policy) {
+	activity := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case activity <- struct{}{}:
+		default:
+		}
+	}
+
+	idleDone := make(chan struct{})
+	defer close(idleDone)
+
+	if pol != nil && pol.IdleTimeout > 0 {
+		go func() {
+			timer := time.NewTimer(pol.IdleTimeout)
+			defer timer.Stop()
+			for {
+				select {
+				case <-activity:
+					if !timer.Stop() {
+						<-timer.C
+					}
+					timer.Reset(pol.IdleTimeout)
+				case <-timer.C:
+					a.Close()
+					b.Close()
+					return
+				case <-idleDone:
+					return
+				}
+			}
+		}()
+	}
+
+	var toA, toB io.Writer = a, b
+	if pol != nil && pol.RateLimit > 0 {
+		toA = withRateLimit(a, pol.RateLimit)
+		toB = withRateLimit(b, pol.RateLimit)
+	}
+
+	wait := make(chan struct{}, 2)
+	pipe := func(dst io.Writer, src io.Reader, record func(int)) {
+		defer func() { wait <- struct{}{} }()
+
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := src.Read(buf)
+			if n > 0 {
+				notify()
+				if _, werr := dst.Write(buf[:n]); werr != nil {
+					return
+				}
+				record(n)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	go pipe(toA, b, s.addIn)
+	go pipe(toB, a, s.addOut)
+
+	<-wait
+	a.Close()
+	s.Close()
+}
@@ -0,0 +1,31 @@
+// Package p2p implements the "libp2p stream mounting" feature used by the
+// `ipfs p2p` commands: tunneling raw byte streams between the local host
+// and a libp2p protocol handler, in either direction.
+package p2p
+
+import (
+	host "gx/ipfs/QmRS46AyqtpJBsf1zmQdeizSDEzo1qkWR7rdEuPFAv8237/go-libp2p-host"
+	peer "gx/ipfs/QmWUswJeUsTwezxeJHYhcBFoGcKzRvN4pe7NB5XbC4wPf3/go-libp2p-peer"
+)
+
+// P2P manages libp2p stream mounting state: currently active listeners
+// (both local-to-remote "forward" and remote-to-local "listen" mounts)
+// and the streams tunneled through them.
+type P2P struct {
+	identity peer.ID
+	peerHost host.Host
+
+	Listeners *Listeners
+	Streams   *StreamRegistry
+}
+
+// NewP2P creates a new P2P struct bound to a libp2p host.
+func NewP2P(identity peer.ID, peerHost host.Host) *P2P {
+	return &P2P{
+		identity: identity,
+		peerHost: peerHost,
+
+		Listeners: newListenersRegistry(),
+		Streams:   newStreamRegistry(),
+	}
+}
@@ -0,0 +1,95 @@
+package p2p
+
+import (
+	"sync"
+	"time"
+)
+
+// Policy bounds resource usage for a single p2p mount: how many concurrent
+// connections it may carry (in aggregate and per remote peer), how long a
+// stream may sit idle before being closed, and how fast bytes may flow
+// through it. A zero value for any field disables that particular limit.
+type Policy struct {
+	MaxConns        int
+	MaxConnsPerPeer int
+	IdleTimeout     time.Duration
+	RateLimit       int64 // bytes per second, 0 disables limiting
+
+	mu          sync.Mutex
+	activeConns int
+	perPeer     map[string]int
+	rejects     uint64
+}
+
+// NewPolicy creates a Policy with the given limits.
+func NewPolicy(maxConns, maxConnsPerPeer int, idleTimeout time.Duration, rateLimit int64) *Policy {
+	return &Policy{
+		MaxConns:        maxConns,
+		MaxConnsPerPeer: maxConnsPerPeer,
+		IdleTimeout:     idleTimeout,
+		RateLimit:       rateLimit,
+		perPeer:         make(map[string]int),
+	}
+}
+
+// Allow reserves a connection slot for peerKey, returning false if doing so
+// would exceed MaxConns or MaxConnsPerPeer. Every Allow that returns true
+// must be matched with a Release. A nil Policy always allows.
+func (pol *Policy) Allow(peerKey string) bool {
+	if pol == nil {
+		return true
+	}
+
+	pol.mu.Lock()
+	defer pol.mu.Unlock()
+
+	if pol.MaxConns > 0 && pol.activeConns >= pol.MaxConns {
+		pol.rejects++
+		return false
+	}
+	if pol.MaxConnsPerPeer > 0 && pol.perPeer[peerKey] >= pol.MaxConnsPerPeer {
+		pol.rejects++
+		return false
+	}
+
+	pol.activeConns++
+	pol.perPeer[peerKey]++
+	return true
+}
+
+// Release frees the connection slot reserved by a successful Allow.
+func (pol *Policy) Release(peerKey string) {
+	if pol == nil {
+		return
+	}
+
+	pol.mu.Lock()
+	defer pol.mu.Unlock()
+
+	pol.activeConns--
+	pol.perPeer[peerKey]--
+	if pol.perPeer[peerKey] <= 0 {
+		delete(pol.perPeer, peerKey)
+	}
+}
+
+// ActiveConns returns the number of connections currently admitted by this
+// policy.
+func (pol *Policy) ActiveConns() int {
+	if pol == nil {
+		return 0
+	}
+	pol.mu.Lock()
+	defer pol.mu.Unlock()
+	return pol.activeConns
+}
+
+// Rejects returns the number of connections this policy has refused.
+func (pol *Policy) Rejects() uint64 {
+	if pol == nil {
+		return 0
+	}
+	pol.mu.Lock()
+	defer pol.mu.Unlock()
+	return pol.rejects
+}
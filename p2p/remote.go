@@ -0,0 +1,154 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"time"
+
+	net "gx/ipfs/QmWSJzRkCMJFHYUQZxKwPX8WA7XipaPtfiwMPARP51ymfn/go-libp2p-net"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+	manet "gx/ipfs/QmYzDkkgAEmrcNzFCiYo6L1dTX4EAG1gZkbtdbd9trL4vd/go-multiaddr-net"
+)
+
+// remoteListener is a Listener created by `ipfs p2p listen`: it registers a
+// libp2p protocol handler and forwards every incoming stream to a local
+// target address.
+type remoteListener struct {
+	p2p *P2P
+
+	proto protocol.ID
+	taddr ma.Multiaddr
+	pol   *Policy
+	acl   *ACL
+
+	// http, if set, treats every incoming stream as a single HTTP/1.1
+	// request and forwards it to the local HTTP server at taddr, instead
+	// of piping the stream's raw bytes to a dialed connection.
+	http bool
+}
+
+func (l *remoteListener) Protocol() protocol.ID       { return l.proto }
+func (l *remoteListener) ListenAddress() ma.Multiaddr { return ma.StringCast("/ipfs/" + l.p2p.identity.Pretty()) }
+func (l *remoteListener) TargetAddress() ma.Multiaddr { return l.taddr }
+func (l *remoteListener) Policy() *Policy             { return l.pol }
+func (l *remoteListener) ACL() *ACL                   { return l.acl }
+
+func (l *remoteListener) Close() error {
+	l.p2p.Listeners.Deregister(l)
+	l.p2p.peerHost.RemoveStreamHandler(l.proto)
+	return nil
+}
+
+func (l *remoteListener) handleStream(remote net.Stream) {
+	remotePeer := remote.Conn().RemotePeer()
+	if !l.acl.Permit(remotePeer, remote.Conn().RemoteMultiaddr()) {
+		remote.Reset()
+		return
+	}
+
+	peerKey := remotePeer.Pretty()
+	if !l.pol.Allow(peerKey) {
+		remote.Reset()
+		return
+	}
+	defer l.pol.Release(peerKey)
+
+	if l.http {
+		l.handleHTTP(remote)
+		return
+	}
+
+	start := time.Now()
+	local, err := manet.Dial(l.taddr)
+	if err != nil {
+		remote.Reset()
+		return
+	}
+
+	s := &Stream{
+		Protocol:   l.proto,
+		OriginAddr: l.ListenAddress(),
+		TargetAddr: l.taddr,
+		Stream:     remote,
+	}
+	l.p2p.Streams.Register(s, time.Since(start))
+
+	tunnel(s, local, remote, l.pol)
+}
+
+// handleHTTP reads a single HTTP/1.1 request off remote, forwards it to
+// the local HTTP server at l.taddr with its Host header rewritten to
+// match, and writes the response back to remote.
+func (l *remoteListener) handleHTTP(remote net.Stream) {
+	req, err := http.ReadRequest(bufio.NewReader(remote))
+	if err != nil {
+		remote.Reset()
+		return
+	}
+
+	start := time.Now()
+	local, err := manet.Dial(l.taddr)
+	if err != nil {
+		remote.Reset()
+		return
+	}
+	defer local.Close()
+
+	s := &Stream{
+		Protocol:   l.proto,
+		OriginAddr: l.ListenAddress(),
+		TargetAddr: l.taddr,
+		Stream:     remote,
+	}
+	l.p2p.Streams.Register(s, time.Since(start))
+	defer s.Close()
+
+	if host, err := httpHost(l.taddr); err == nil && host != "" {
+		req.Host = host
+		req.URL.Host = host
+	}
+	req.RequestURI = ""
+
+	if err := req.Write(countingWriter{local, s.addOut}); err != nil {
+		return
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(countingReader{local, s.addIn}), req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	resp.Write(remote)
+}
+
+// ForwardRemote registers proto as a libp2p service that forwards every
+// incoming stream to target, subject to pol and acl (pol may be nil for
+// no resource limits; acl defaults to an empty, allow-everyone ACL when
+// nil, so `ipfs p2p acl add` always has a live ACL to attach entries to
+// later, even for mounts created without any ACL flags). If httpMode is
+// set, each stream is treated as a single HTTP/1.1 request forwarded to
+// the local HTTP server at target, with its Host header rewritten to
+// match, rather than as a raw byte pipe.
+func (p *P2P) ForwardRemote(ctx context.Context, proto protocol.ID, target ma.Multiaddr, pol *Policy, acl *ACL, httpMode bool) (Listener, error) {
+	if acl == nil {
+		acl = NewACL()
+	}
+
+	l := &remoteListener{
+		p2p:   p,
+		proto: proto,
+		taddr: target,
+		pol:   pol,
+		acl:   acl,
+
+		http: httpMode,
+	}
+
+	p.Listeners.Register(l)
+	p.peerHost.SetStreamHandler(proto, l.handleStream)
+
+	return l, nil
+}
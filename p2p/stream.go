@@ -0,0 +1,151 @@
+package p2p
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	net "gx/ipfs/QmWSJzRkCMJFHYUQZxKwPX8WA7XipaPtfiwMPARP51ymfn/go-libp2p-net"
+	protocol "gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+	ma "gx/ipfs/QmYmsdtJ3HsodkePE3eU3TsCaP2YvPZJ4LoXnNkDE5Tpt7/go-multiaddr"
+)
+
+// Stream is an active p2p tunnel, forwarding raw bytes between a local
+// multiaddr and a remote one over a libp2p stream.
+type Stream struct {
+	ID uint64
+
+	Protocol protocol.ID
+
+	OriginAddr ma.Multiaddr
+	TargetAddr ma.Multiaddr
+
+	Stream net.Stream
+
+	OpenedAt time.Time
+
+	mu           sync.Mutex
+	bytesIn      uint64
+	bytesOut     uint64
+	lastActivity time.Time
+	closeOnce    sync.Once
+
+	registry *StreamRegistry
+	stats    *ProtocolStats
+}
+
+// touch records activity on the stream for LastActivity. It is called on
+// every BytesIn/BytesOut update; tunnel's idle timeout is tracked
+// separately.
+func (s *Stream) touch() {
+	s.mu.Lock()
+	s.lastActivity = time.Now()
+	s.mu.Unlock()
+}
+
+func (s *Stream) addIn(n int) {
+	s.touch()
+	s.mu.Lock()
+	s.bytesIn += uint64(n)
+	s.mu.Unlock()
+	s.stats.addBytesIn(n)
+}
+
+func (s *Stream) addOut(n int) {
+	s.touch()
+	s.mu.Lock()
+	s.bytesOut += uint64(n)
+	s.mu.Unlock()
+	s.stats.addBytesOut(n)
+}
+
+// BytesIn returns the number of bytes received from the libp2p peer side of
+// the tunnel so far.
+func (s *Stream) BytesIn() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bytesIn
+}
+
+// BytesOut returns the number of bytes sent to the libp2p peer side of the
+// tunnel so far.
+func (s *Stream) BytesOut() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bytesOut
+}
+
+// LastActivity returns when a byte was last forwarded in either direction,
+// or the zero Time if nothing has been forwarded yet.
+func (s *Stream) LastActivity() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastActivity
+}
+
+// finish records the stream's terminal state exactly once, however it gets
+// torn down: an explicit Reset/Close, or tunnel closing it once either
+// direction of the copy ends.
+func (s *Stream) finish(reset bool) {
+	s.closeOnce.Do(func() {
+		s.stats.recordClose(reset)
+		s.registry.Deregister(s.ID)
+	})
+}
+
+// Reset closes the stream, signaling an abnormal termination to the peer.
+func (s *Stream) Reset() error {
+	s.finish(true)
+	return s.Stream.Reset()
+}
+
+// Close closes the stream normally.
+func (s *Stream) Close() error {
+	s.finish(false)
+	return s.Stream.Close()
+}
+
+// StreamRegistry tracks active streams by handler ID, plus aggregate
+// counters per protocol for `ipfs p2p stream stats` and PrometheusCollector.
+type StreamRegistry struct {
+	sync.Mutex
+
+	Streams map[uint64]*Stream
+	nextID  uint64
+
+	stats map[protocol.ID]*ProtocolStats
+}
+
+func newStreamRegistry() *StreamRegistry {
+	return &StreamRegistry{
+		Streams: make(map[uint64]*Stream),
+		stats:   make(map[protocol.ID]*ProtocolStats),
+	}
+}
+
+// Register adds a stream to the registry, assigns it a handler ID, and
+// records its connect latency - how long it took to establish, from the
+// moment the tunnel started accepting/dialing to the moment both ends were
+// ready to pipe data.
+func (r *StreamRegistry) Register(s *Stream, connectLatency time.Duration) uint64 {
+	r.Lock()
+	defer r.Unlock()
+
+	id := atomic.AddUint64(&r.nextID, 1)
+	s.ID = id
+	s.OpenedAt = time.Now()
+	s.registry = r
+	s.stats = r.statsForLocked(s.Protocol)
+	r.Streams[id] = s
+
+	s.stats.recordOpen(connectLatency)
+
+	return id
+}
+
+// Deregister removes a stream from the registry by handler ID.
+func (r *StreamRegistry) Deregister(id uint64) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.Streams, id)
+}